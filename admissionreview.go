@@ -0,0 +1,139 @@
+package ezadmis
+
+import (
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// decodeAdmissionReview decodes an incoming request body into a normalized
+// admissionv1.AdmissionReview, regardless of whether the API server sent
+// admission.k8s.io/v1 or admission.k8s.io/v1beta1. The GroupVersionKind of
+// the original request is returned alongside it, so the response can be
+// marshaled back using the same version the client negotiated.
+func decodeAdmissionReview(data []byte) (review *admissionv1.AdmissionReview, gvk schema.GroupVersionKind, err error) {
+	obj, actualGVK, decodeErr := codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if decodeErr != nil {
+		err = decodeErr
+		return
+	}
+
+	gvk = *actualGVK
+
+	switch v := obj.(type) {
+	case *admissionv1.AdmissionReview:
+		review = v
+	case *admissionv1beta1.AdmissionReview:
+		review = convertAdmissionReviewFromV1beta1(v)
+	default:
+		err = fmt.Errorf("unsupported AdmissionReview type: %T", obj)
+	}
+	return
+}
+
+// encodeAdmissionReview marshals a normalized admissionv1.AdmissionReview
+// back into the wire format of gvk, converting to v1beta1 when that's what
+// the client originally sent.
+func encodeAdmissionReview(review *admissionv1.AdmissionReview, gvk schema.GroupVersionKind) (data []byte, err error) {
+	typeMeta := metav1.TypeMeta{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+	}
+
+	switch gvk.Version {
+	case admissionv1beta1.SchemeGroupVersion.Version:
+		out := convertAdmissionReviewToV1beta1(review)
+		out.TypeMeta = typeMeta
+		return json.Marshal(out)
+	default:
+		review.TypeMeta = typeMeta
+		return json.Marshal(review)
+	}
+}
+
+// convertAdmissionReviewFromV1beta1 converts in field-by-field rather than
+// via a blanket struct conversion: Operation and PatchType are distinct
+// named types between the two versions (both string-backed, but not
+// identical), so admissionv1.AdmissionRequest(*in.Request) does not compile
+func convertAdmissionReviewFromV1beta1(in *admissionv1beta1.AdmissionReview) *admissionv1.AdmissionReview {
+	out := &admissionv1.AdmissionReview{}
+	if req := in.Request; req != nil {
+		out.Request = &admissionv1.AdmissionRequest{
+			UID:                req.UID,
+			Kind:               req.Kind,
+			Resource:           req.Resource,
+			SubResource:        req.SubResource,
+			RequestKind:        req.RequestKind,
+			RequestResource:    req.RequestResource,
+			RequestSubResource: req.RequestSubResource,
+			Name:               req.Name,
+			Namespace:          req.Namespace,
+			Operation:          admissionv1.Operation(req.Operation),
+			UserInfo:           req.UserInfo,
+			Object:             req.Object,
+			OldObject:          req.OldObject,
+			DryRun:             req.DryRun,
+			Options:            req.Options,
+		}
+	}
+	if res := in.Response; res != nil {
+		out.Response = &admissionv1.AdmissionResponse{
+			UID:              res.UID,
+			Allowed:          res.Allowed,
+			Result:           res.Result,
+			Patch:            res.Patch,
+			AuditAnnotations: res.AuditAnnotations,
+			Warnings:         res.Warnings,
+		}
+		if res.PatchType != nil {
+			patchType := admissionv1.PatchType(*res.PatchType)
+			out.Response.PatchType = &patchType
+		}
+	}
+	return out
+}
+
+// convertAdmissionReviewToV1beta1 is the inverse of
+// convertAdmissionReviewFromV1beta1, see its comment for why this can't be a
+// blanket struct conversion
+func convertAdmissionReviewToV1beta1(in *admissionv1.AdmissionReview) *admissionv1beta1.AdmissionReview {
+	out := &admissionv1beta1.AdmissionReview{}
+	if req := in.Request; req != nil {
+		out.Request = &admissionv1beta1.AdmissionRequest{
+			UID:                req.UID,
+			Kind:               req.Kind,
+			Resource:           req.Resource,
+			SubResource:        req.SubResource,
+			RequestKind:        req.RequestKind,
+			RequestResource:    req.RequestResource,
+			RequestSubResource: req.RequestSubResource,
+			Name:               req.Name,
+			Namespace:          req.Namespace,
+			Operation:          admissionv1beta1.Operation(req.Operation),
+			UserInfo:           req.UserInfo,
+			Object:             req.Object,
+			OldObject:          req.OldObject,
+			DryRun:             req.DryRun,
+			Options:            req.Options,
+		}
+	}
+	if res := in.Response; res != nil {
+		out.Response = &admissionv1beta1.AdmissionResponse{
+			UID:              res.UID,
+			Allowed:          res.Allowed,
+			Result:           res.Result,
+			Patch:            res.Patch,
+			AuditAnnotations: res.AuditAnnotations,
+			Warnings:         res.Warnings,
+		}
+		if res.PatchType != nil {
+			patchType := admissionv1beta1.PatchType(*res.PatchType)
+			out.Response.PatchType = &patchType
+		}
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package ezadmis_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yankeguo/ezadmis"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestWrapWebhookHandler_V1beta1RoundTrip(t *testing.T) {
+	reqReview := admissionv1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "admission.k8s.io/v1beta1",
+			Kind:       "AdmissionReview",
+		},
+		Request: &admissionv1beta1.AdmissionRequest{
+			UID:       types.UID("test-uid"),
+			Operation: admissionv1beta1.Create,
+		},
+	}
+	body, err := json.Marshal(reqReview)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := ezadmis.WrapWebhookHandler(ezadmis.WrapWebhookHandlerOptions{}, func(
+		ctx context.Context,
+		req *admissionv1.AdmissionRequest,
+		rw ezadmis.WebhookResponseWriter,
+	) error {
+		rw.PatchAdd("/metadata/labels/injected", "true")
+		return nil
+	})
+
+	rw := httptest.NewRecorder()
+	handler(rw, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+
+	var resReview admissionv1beta1.AdmissionReview
+	if err := json.Unmarshal(rw.Body.Bytes(), &resReview); err != nil {
+		t.Fatalf("failed to decode response as admission/v1beta1: %v", err)
+	}
+	if resReview.TypeMeta.APIVersion != "admission.k8s.io/v1beta1" {
+		t.Fatalf("expected a v1beta1 response, got apiVersion %q", resReview.TypeMeta.APIVersion)
+	}
+	if resReview.Response == nil || !resReview.Response.Allowed {
+		t.Fatalf("expected an allowed response, got %+v", resReview.Response)
+	}
+	if resReview.Response.UID != types.UID("test-uid") {
+		t.Fatalf("expected UID to round-trip, got %q", resReview.Response.UID)
+	}
+	if len(resReview.Response.Patch) == 0 {
+		t.Fatal("expected the recorded patch to be present in the response")
+	}
+}
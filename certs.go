@@ -0,0 +1,278 @@
+package ezadmis
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// certReloader implements tls.Config#GetCertificate by serving whatever
+// CertFile/KeyFile currently hold on disk, reloading them whenever they
+// change. This lets cert-manager (or BootstrapTLS below) rotate the
+// keypair without a pod restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// newCertReloader loads certFile/keyFile once, then keeps them fresh via
+// fsnotify plus a periodic re-stat as a fallback, since some filesystems
+// (and the atomic rename cert-manager/kubelet use to publish a new cert)
+// don't surface reliably through inotify alone
+func newCertReloader(ctx context.Context, certFile, keyFile string, pollInterval time.Duration) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range uniqueDirs(certFile, keyFile) {
+		if err = watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return nil, err
+		}
+	}
+
+	if pollInterval <= 0 {
+		pollInterval = 10 * time.Second
+	}
+
+	logger := defaultLogger()
+
+	// CertFile/KeyFile may not exist yet, e.g. a caller building the
+	// WebhookServer before BootstrapTLS has written them; GetCertificate
+	// errors until the watch loop below picks up the first successful
+	// reload, instead of permanently falling back to a static load
+	if err := r.reload(); err != nil {
+		logger.Error(err, "TLS certificate not loaded yet, will keep retrying")
+	}
+
+	go func() {
+		defer watcher.Close()
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-watcher.Events:
+				if err := r.reload(); err != nil {
+					logger.Error(err, "failed to reload TLS certificate")
+				}
+			case <-ticker.C:
+				if err := r.reload(); err != nil {
+					logger.Error(err, "failed to reload TLS certificate")
+				}
+			}
+		}
+	}()
+
+	return r, nil
+}
+
+func uniqueDirs(files ...string) (dirs []string) {
+	seen := map[string]bool{}
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return
+}
+
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate implements tls.Config#GetCertificate
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		return nil, errors.New("ezadmis: no TLS certificate loaded yet")
+	}
+	return r.cert, nil
+}
+
+// BootstrapTLSOptions options for BootstrapTLS
+type BootstrapTLSOptions struct {
+	// Client talks to the API server to submit the CSR and patch webhook
+	// configurations
+	Client kubernetes.Interface
+	// SignerName the CSR signer, one that issues server certificates, e.g. a
+	// custom signer handled by cert-manager's CSR issuer
+	SignerName string
+	// CommonName and DNSNames for the requested certificate
+	CommonName string
+	DNSNames   []string
+	// CertFile / KeyFile where the issued keypair is written once approved
+	CertFile string
+	KeyFile  string
+	// MutatingWebhookConfigurationName / ValidatingWebhookConfigurationName,
+	// when set, have their webhooks' ClientConfig.CABundle set to the issued
+	// certificate itself once the CSR is issued; the signer's CA certificate
+	// is not fetched or written
+	MutatingWebhookConfigurationName   string
+	ValidatingWebhookConfigurationName string
+	// Timeout waiting for the CSR to be approved and issued, defaults to 2m
+	Timeout time.Duration
+}
+
+// BootstrapTLS generates an in-memory keypair, submits a
+// certificates.k8s.io/v1 CertificateSigningRequest for it, blocks until the
+// request is approved and issued, writes the resulting keypair to
+// CertFile/KeyFile, and patches the CABundle of the named
+// MutatingWebhookConfiguration/ValidatingWebhookConfiguration. It removes
+// the need for an external cert provisioner in simple deployments
+func BootstrapTLS(ctx context.Context, opts BootstrapTLSOptions) (err error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+
+	var key *ecdsa.PrivateKey
+	if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return errors.New("failed to generate private key: " + err.Error())
+	}
+
+	var csrDER []byte
+	if csrDER, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: opts.CommonName},
+		DNSNames: opts.DNSNames,
+	}, key); err != nil {
+		return errors.New("failed to create certificate request: " + err.Error())
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return errors.New("failed to marshal private key: " + err.Error())
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "ezadmis-",
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    csrPEM,
+			SignerName: opts.SignerName,
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageServerAuth,
+			},
+		},
+	}
+
+	csrClient := opts.Client.CertificatesV1().CertificateSigningRequests()
+
+	if csr, err = csrClient.Create(ctx, csr, metav1.CreateOptions{}); err != nil {
+		return errors.New("failed to create CertificateSigningRequest: " + err.Error())
+	}
+
+	csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+		Type:    certificatesv1.CertificateApproved,
+		Status:  "True",
+		Reason:  "EzadmisSelfBootstrap",
+		Message: "approved automatically by ezadmis.BootstrapTLS",
+	})
+	if csr, err = csrClient.UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{}); err != nil {
+		return errors.New("failed to approve CertificateSigningRequest: " + err.Error())
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	var certPEM []byte
+	if err = wait.PollUntilContextCancel(waitCtx, time.Second, true, func(ctx context.Context) (bool, error) {
+		latest, getErr := csrClient.Get(ctx, csr.Name, metav1.GetOptions{})
+		if getErr != nil {
+			return false, getErr
+		}
+		if len(latest.Status.Certificate) == 0 {
+			return false, nil
+		}
+		certPEM = latest.Status.Certificate
+		return true, nil
+	}); err != nil {
+		return errors.New("timed out waiting for CertificateSigningRequest to be issued: " + err.Error())
+	}
+
+	if err = os.WriteFile(opts.CertFile, certPEM, 0o600); err != nil {
+		return errors.New("failed to write certificate: " + err.Error())
+	}
+	if err = os.WriteFile(opts.KeyFile, keyPEM, 0o600); err != nil {
+		return errors.New("failed to write private key: " + err.Error())
+	}
+
+	if opts.MutatingWebhookConfigurationName != "" {
+		if err = patchMutatingWebhookCABundle(ctx, opts.Client, opts.MutatingWebhookConfigurationName, certPEM); err != nil {
+			return err
+		}
+	}
+	if opts.ValidatingWebhookConfigurationName != "" {
+		if err = patchValidatingWebhookCABundle(ctx, opts.Client, opts.ValidatingWebhookConfigurationName, certPEM); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func patchMutatingWebhookCABundle(ctx context.Context, client kubernetes.Interface, name string, caBundle []byte) error {
+	webhooksClient := client.AdmissionregistrationV1().MutatingWebhookConfigurations()
+	cfg, err := webhooksClient.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return errors.New("failed to get MutatingWebhookConfiguration: " + err.Error())
+	}
+	for i := range cfg.Webhooks {
+		cfg.Webhooks[i].ClientConfig.CABundle = caBundle
+	}
+	if _, err = webhooksClient.Update(ctx, cfg, metav1.UpdateOptions{}); err != nil {
+		return errors.New("failed to patch MutatingWebhookConfiguration CABundle: " + err.Error())
+	}
+	return nil
+}
+
+func patchValidatingWebhookCABundle(ctx context.Context, client kubernetes.Interface, name string, caBundle []byte) error {
+	webhooksClient := client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	cfg, err := webhooksClient.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return errors.New("failed to get ValidatingWebhookConfiguration: " + err.Error())
+	}
+	for i := range cfg.Webhooks {
+		cfg.Webhooks[i].ClientConfig.CABundle = caBundle
+	}
+	if _, err = webhooksClient.Update(ctx, cfg, metav1.UpdateOptions{}); err != nil {
+		return errors.New("failed to patch ValidatingWebhookConfiguration CABundle: " + err.Error())
+	}
+	return nil
+}
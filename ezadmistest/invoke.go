@@ -0,0 +1,56 @@
+package ezadmistest
+
+import (
+	"context"
+	"encoding/json"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/yankeguo/ezadmis"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InvokeResult the outcome of running a WebhookHandler through Invoke
+type InvokeResult struct {
+	// Allowed whether the request was allowed
+	Allowed bool
+	// Status the metav1.Status of the response, nil when Allowed is true
+	Status *metav1.Status
+	// Patches the raw JSONPatch operations recorded by the handler
+	Patches []map[string]interface{}
+	// Object is req.Object.Raw with Patches applied, nil if there were no
+	// patches or req.Object.Raw was empty
+	Object []byte
+}
+
+// Invoke runs handler against req using an in-memory Recorder and returns
+// the collected patches, deny status, and the object after applying the
+// recorded JSONPatch to req.Object.Raw
+func Invoke(ctx context.Context, handler ezadmis.WebhookHandler, req *admissionv1.AdmissionRequest) (result InvokeResult, err error) {
+	rec := NewRecorder()
+
+	if err = handler(ctx, req, rec); err != nil {
+		return
+	}
+
+	result.Patches = rec.Patches()
+	result.Status = rec.Status()
+	result.Allowed = rec.Allowed()
+
+	if len(result.Patches) == 0 || req == nil || len(req.Object.Raw) == 0 {
+		return
+	}
+
+	var patchJSON []byte
+	if patchJSON, err = json.Marshal(result.Patches); err != nil {
+		return
+	}
+
+	var patch jsonpatch.Patch
+	if patch, err = jsonpatch.DecodePatch(patchJSON); err != nil {
+		return
+	}
+
+	result.Object, err = patch.Apply(req.Object.Raw)
+	return
+}
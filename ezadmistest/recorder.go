@@ -0,0 +1,102 @@
+// Package ezadmistest provides an in-process harness for unit-testing an
+// ezadmis.WebhookHandler without spinning up an HTTPS server, mirroring the
+// ergonomics of net/http/httptest.
+package ezadmistest
+
+import (
+	"github.com/yankeguo/ezadmis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Recorder is an in-memory ezadmis.WebhookResponseWriter that collects the
+// patches and deny/status set by a WebhookHandler under test
+type Recorder struct {
+	patches []map[string]interface{}
+	deny    string
+	status  *metav1.Status
+}
+
+var _ ezadmis.WebhookResponseWriter = (*Recorder)(nil)
+
+// NewRecorder creates a Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Deny implements ezadmis.WebhookResponseWriter
+func (r *Recorder) Deny(deny string) {
+	r.deny = deny
+}
+
+// DenyStatus implements ezadmis.WebhookResponseWriter
+func (r *Recorder) DenyStatus(status metav1.Status) {
+	r.status = &status
+}
+
+// PatchRaw implements ezadmis.WebhookResponseWriter
+func (r *Recorder) PatchRaw(patch map[string]interface{}) {
+	r.patches = append(r.patches, patch)
+}
+
+// PatchAdd implements ezadmis.WebhookResponseWriter
+func (r *Recorder) PatchAdd(path string, value interface{}) {
+	r.PatchRaw(map[string]interface{}{"op": "add", "path": path, "value": value})
+}
+
+// PatchRemove implements ezadmis.WebhookResponseWriter
+func (r *Recorder) PatchRemove(path string) {
+	r.PatchRaw(map[string]interface{}{"op": "remove", "path": path})
+}
+
+// PatchReplace implements ezadmis.WebhookResponseWriter
+func (r *Recorder) PatchReplace(path string, value interface{}) {
+	r.PatchRaw(map[string]interface{}{"op": "replace", "path": path, "value": value})
+}
+
+// PatchCopy implements ezadmis.WebhookResponseWriter
+func (r *Recorder) PatchCopy(path string, from string) {
+	r.PatchRaw(map[string]interface{}{"op": "copy", "path": path, "from": from})
+}
+
+// PatchMove implements ezadmis.WebhookResponseWriter
+func (r *Recorder) PatchMove(path string, from string) {
+	r.PatchRaw(map[string]interface{}{"op": "move", "path": path, "from": from})
+}
+
+// PatchTest implements ezadmis.WebhookResponseWriter
+func (r *Recorder) PatchTest(path string, value interface{}) {
+	r.PatchRaw(map[string]interface{}{"op": "test", "path": path, "value": value})
+}
+
+// Patches returns the JSONPatch operations recorded so far
+func (r *Recorder) Patches() []map[string]interface{} {
+	return r.patches
+}
+
+// DenyMessage returns the message passed to Deny, empty if the request
+// wasn't denied via Deny
+func (r *Recorder) DenyMessage() string {
+	return r.deny
+}
+
+// Status returns the metav1.Status recorded for this request: whatever was
+// passed to DenyStatus, or one synthesized from DenyMessage, or nil if the
+// request was allowed
+func (r *Recorder) Status() *metav1.Status {
+	if r.status != nil {
+		return r.status
+	}
+	if r.deny != "" {
+		return &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: r.deny,
+			Reason:  metav1.StatusReasonBadRequest,
+		}
+	}
+	return nil
+}
+
+// Allowed reports whether the request was allowed
+func (r *Recorder) Allowed() bool {
+	return r.Status() == nil
+}
@@ -0,0 +1,57 @@
+package ezadmistest
+
+import (
+	"encoding/json"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// NewRequestForObject builds a well-formed AdmissionRequest for obj: a
+// fresh UID, the GroupVersionKind obj is registered under in scheme, and
+// Object marshaled from obj — except for admissionv1.Delete, which mirrors
+// the real API server and leaves Object empty and populates OldObject
+// instead, and admissionv1.Update, which populates both
+func NewRequestForObject(scheme *runtime.Scheme, op admissionv1.Operation, obj runtime.Object) (req *admissionv1.AdmissionRequest, err error) {
+	gvks, _, err := scheme.ObjectKinds(obj)
+	if err != nil {
+		return nil, err
+	}
+	gvk := gvks[0]
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	req = &admissionv1.AdmissionRequest{
+		UID: uuid.NewUUID(),
+		Kind: metav1.GroupVersionKind{
+			Group:   gvk.Group,
+			Version: gvk.Version,
+			Kind:    gvk.Kind,
+		},
+		Operation: op,
+	}
+
+	switch op {
+	case admissionv1.Delete:
+		// the API server leaves Object empty on DELETE; the object being
+		// deleted is only available via OldObject
+		req.OldObject = runtime.RawExtension{Raw: raw}
+	case admissionv1.Update:
+		req.Object = runtime.RawExtension{Raw: raw}
+		req.OldObject = runtime.RawExtension{Raw: raw}
+	default:
+		req.Object = runtime.RawExtension{Raw: raw}
+	}
+
+	if accessor, ok := obj.(metav1.Object); ok {
+		req.Name = accessor.GetName()
+		req.Namespace = accessor.GetNamespace()
+	}
+
+	return req, nil
+}
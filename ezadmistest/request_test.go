@@ -0,0 +1,80 @@
+package ezadmistest_test
+
+import (
+	"testing"
+
+	"github.com/yankeguo/ezadmis/ezadmistest"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNewRequestForObject(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	pod := &corev1.Pod{}
+	pod.Name = "demo"
+	pod.Namespace = "default"
+
+	req, err := ezadmistest.NewRequestForObject(scheme, admissionv1.Create, pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.UID == "" {
+		t.Fatal("expected a generated UID")
+	}
+	if req.Kind.Kind != "Pod" {
+		t.Fatalf("expected Kind Pod, got %q", req.Kind.Kind)
+	}
+	if req.Name != "demo" || req.Namespace != "default" {
+		t.Fatalf("expected name/namespace copied from obj, got %q/%q", req.Name, req.Namespace)
+	}
+	if len(req.Object.Raw) == 0 {
+		t.Fatal("expected Object.Raw to be populated")
+	}
+	if len(req.OldObject.Raw) != 0 {
+		t.Fatal("expected OldObject.Raw to be empty for a Create request")
+	}
+}
+
+func TestNewRequestForObject_Update(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	pod := &corev1.Pod{}
+	pod.Name = "demo"
+
+	req, err := ezadmistest.NewRequestForObject(scheme, admissionv1.Update, pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Object.Raw) == 0 || len(req.OldObject.Raw) == 0 {
+		t.Fatal("expected both Object.Raw and OldObject.Raw to be populated for an Update request")
+	}
+}
+
+func TestNewRequestForObject_Delete(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	pod := &corev1.Pod{}
+	pod.Name = "demo"
+
+	req, err := ezadmistest.NewRequestForObject(scheme, admissionv1.Delete, pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Object.Raw) != 0 {
+		t.Fatal("expected Object.Raw to be empty for a Delete request, matching the real API server")
+	}
+	if len(req.OldObject.Raw) == 0 {
+		t.Fatal("expected OldObject.Raw to hold the object being deleted")
+	}
+}
@@ -0,0 +1,33 @@
+package ezadmis
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/stdr"
+)
+
+type loggerContextKey struct{}
+
+// LoggerFrom returns the logr.Logger carried in ctx by WrapWebhookHandler,
+// already bound with the uid/kind/namespace/name/operation/username fields
+// of the current admission request. It falls back to logr.Discard() when
+// ctx carries no logger, so it's always safe to call from a WebhookHandler
+func LoggerFrom(ctx context.Context) logr.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(logr.Logger); ok {
+		return logger
+	}
+	return logr.Discard()
+}
+
+func loggerInto(ctx context.Context, logger logr.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// defaultLogger returns the logr.Logger used by WrapWebhookHandler/
+// WebhookServer when no Logger option is supplied
+func defaultLogger() logr.Logger {
+	return stdr.New(log.New(os.Stderr, "", log.LstdFlags))
+}
@@ -0,0 +1,130 @@
+package ezadmis
+
+import (
+	"errors"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors WrapWebhookHandler/WebhookServer
+// instrument every admission request with: per-path request counts by
+// outcome, handler latency, JSONPatch size, and decode failures
+type Metrics struct {
+	requestsTotal *prometheus.CounterVec
+	latency       *prometheus.HistogramVec
+	patchBytes    *prometheus.HistogramVec
+	decodeErrors  *prometheus.CounterVec
+}
+
+// NewMetrics creates a Metrics and registers its collectors with reg. A nil
+// reg registers with prometheus.DefaultRegisterer. Calling NewMetrics again
+// with a registerer that already has these collectors (e.g. a second
+// WebhookServer sharing prometheus.DefaultRegisterer in the same process)
+// reuses the existing collectors instead of panicking
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	m := &Metrics{
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ezadmis_admission_requests_total",
+			Help: "Total number of admission requests handled, labeled by outcome",
+		}, []string{"path", "resource", "operation", "dry_run", "result"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ezadmis_admission_request_duration_seconds",
+			Help:    "Latency of WebhookHandler execution, in seconds",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"path", "resource", "operation", "dry_run"}),
+		patchBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ezadmis_admission_patch_bytes",
+			Help:    "Size in bytes of the JSONPatch returned for a request",
+			Buckets: prometheus.ExponentialBuckets(16, 2, 10),
+		}, []string{"path", "resource", "operation", "dry_run"}),
+		decodeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ezadmis_admission_decode_errors_total",
+			Help: "Total number of AdmissionReview decode failures",
+		}, []string{"path"}),
+	}
+
+	m.requestsTotal = registerOrReuseCounterVec(reg, m.requestsTotal)
+	m.latency = registerOrReuseHistogramVec(reg, m.latency)
+	m.patchBytes = registerOrReuseHistogramVec(reg, m.patchBytes)
+	m.decodeErrors = registerOrReuseCounterVec(reg, m.decodeErrors)
+
+	return m
+}
+
+func registerOrReuseCounterVec(reg prometheus.Registerer, c *prometheus.CounterVec) *prometheus.CounterVec {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(*prometheus.CounterVec); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+func registerOrReuseHistogramVec(reg prometheus.Registerer, c *prometheus.HistogramVec) *prometheus.HistogramVec {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(*prometheus.HistogramVec); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// observeDecodeError records a failure to decode the incoming AdmissionReview
+func (m *Metrics) observeDecodeError(path string) {
+	if m == nil {
+		return
+	}
+	m.decodeErrors.WithLabelValues(path).Inc()
+}
+
+// observe records the outcome of a completed admission request
+func (m *Metrics) observe(path string, req *admissionv1.AdmissionRequest, start time.Time, patchBytes int, result string) {
+	if m == nil {
+		return
+	}
+
+	var resource, operation, dryRun string
+	if req != nil {
+		resource = req.Resource.Resource
+		operation = string(req.Operation)
+		if req.DryRun != nil && *req.DryRun {
+			dryRun = "true"
+		} else {
+			dryRun = "false"
+		}
+	}
+
+	m.requestsTotal.WithLabelValues(path, resource, operation, dryRun, result).Inc()
+	m.latency.WithLabelValues(path, resource, operation, dryRun).Observe(time.Since(start).Seconds())
+	if patchBytes > 0 {
+		m.patchBytes.WithLabelValues(path, resource, operation, dryRun).Observe(float64(patchBytes))
+	}
+}
+
+// gathererFor returns the prometheus.Gatherer backing reg, for mounting
+// /metrics. Falls back to prometheus.DefaultGatherer when reg is nil or
+// doesn't also implement Gatherer (e.g. a bare prometheus.Registerer)
+func gathererFor(reg prometheus.Registerer) prometheus.Gatherer {
+	if reg == nil {
+		return prometheus.DefaultGatherer
+	}
+	if g, ok := reg.(prometheus.Gatherer); ok {
+		return g
+	}
+	return prometheus.DefaultGatherer
+}
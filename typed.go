@@ -0,0 +1,166 @@
+package ezadmis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	jsonpatch "github.com/mattbaird/jsonpatch"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// CustomDefaulter mutates a typed object before it's admitted, modeled
+// after controller-runtime's admission.CustomDefaulter
+type CustomDefaulter interface {
+	// Default mutates obj in place
+	Default(ctx context.Context, obj runtime.Object) error
+}
+
+// CustomValidator validates a typed object, modeled after controller-runtime's
+// admission.CustomValidator. ValidateUpdate receives both the previous and
+// new state; ValidateCreate and ValidateDelete each receive a single
+// object — the object being created, or the object being deleted (decoded
+// from req.OldObject.Raw, since the API server leaves req.Object.Raw empty
+// on DELETE)
+type CustomValidator interface {
+	ValidateCreate(ctx context.Context, obj runtime.Object) error
+	ValidateUpdate(ctx context.Context, oldObj runtime.Object, obj runtime.Object) error
+	ValidateDelete(ctx context.Context, obj runtime.Object) error
+}
+
+// StatusError is an error that carries a specific HTTP status Code and
+// metav1.StatusReason, for CustomDefaulter/CustomValidator callbacks that
+// need more than the default 400 Bad Request used by WebhookResponseWriter#Deny
+type StatusError struct {
+	Code    int32
+	Reason  metav1.StatusReason
+	Message string
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// NewStatusError creates a StatusError
+func NewStatusError(code int32, reason metav1.StatusReason, message string) error {
+	return &StatusError{Code: code, Reason: reason, Message: message}
+}
+
+// TypedWebhookHandlerOptions options for NewTypedWebhookHandler
+type TypedWebhookHandlerOptions struct {
+	// Object a zero-value instance of the resource type this handler handles,
+	// e.g. &corev1.Pod{}; a fresh instance is decoded into for every request
+	Object runtime.Object
+	// Defaulter optional, mutates the decoded object
+	Defaulter CustomDefaulter
+	// Validator optional, validates the decoded object
+	Validator CustomValidator
+}
+
+// NewTypedWebhookHandler builds a WebhookHandler that decodes req.Object.Raw
+// (and req.OldObject.Raw, for updates and deletes) into a typed Go value, runs
+// Defaulter/Validator against it, and turns whatever mutation Defaulter
+// performed into a JSONPatch automatically, so handlers never have to call
+// WebhookResponseWriter#PatchAdd/PatchReplace themselves
+func NewTypedWebhookHandler(opts TypedWebhookHandlerOptions) WebhookHandler {
+	return func(ctx context.Context, req *admissionv1.AdmissionRequest, rw WebhookResponseWriter) (err error) {
+		if req == nil {
+			return errors.New("ezadmis: NewTypedWebhookHandler requires a non-nil AdmissionRequest")
+		}
+
+		obj := opts.Object.DeepCopyObject()
+		if len(req.Object.Raw) > 0 {
+			if err = json.Unmarshal(req.Object.Raw, obj); err != nil {
+				return errors.New("failed to decode request object: " + err.Error())
+			}
+		}
+
+		var oldObj runtime.Object
+		if len(req.OldObject.Raw) > 0 {
+			oldObj = opts.Object.DeepCopyObject()
+			if err = json.Unmarshal(req.OldObject.Raw, oldObj); err != nil {
+				return errors.New("failed to decode old request object: " + err.Error())
+			}
+		}
+
+		original := obj.DeepCopyObject()
+
+		// there's no real object to default on DELETE (req.Object.Raw is
+		// empty, so obj is still a zero-value stub) and K8s ignores any
+		// patch returned for a DELETE anyway
+		if opts.Defaulter != nil && req.Operation != admissionv1.Delete {
+			if err = opts.Defaulter.Default(ctx, obj); err != nil {
+				denyWithError(rw, err)
+				return nil
+			}
+		}
+
+		if opts.Validator != nil {
+			var validateErr error
+			switch req.Operation {
+			case admissionv1.Create:
+				validateErr = opts.Validator.ValidateCreate(ctx, obj)
+			case admissionv1.Update:
+				validateErr = opts.Validator.ValidateUpdate(ctx, oldObj, obj)
+			case admissionv1.Delete:
+				// req.Object.Raw is empty on DELETE; the object being
+				// deleted is only available via req.OldObject.Raw
+				validateErr = opts.Validator.ValidateDelete(ctx, oldObj)
+			}
+			if validateErr != nil {
+				denyWithError(rw, validateErr)
+				return nil
+			}
+		}
+
+		if opts.Defaulter == nil {
+			return nil
+		}
+
+		var patch []jsonpatch.JsonPatchOperation
+		if patch, err = diffJSONPatch(original, obj); err != nil {
+			return errors.New("failed to diff mutated object: " + err.Error())
+		}
+		for _, op := range patch {
+			rw.PatchRaw(map[string]interface{}{
+				"op":    op.Operation,
+				"path":  op.Path,
+				"value": op.Value,
+			})
+		}
+		return nil
+	}
+}
+
+// denyWithError denies the current request with rw, unwrapping a
+// *StatusError into its full metav1.Status when present
+func denyWithError(rw WebhookResponseWriter, err error) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		rw.DenyStatus(metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: statusErr.Message,
+			Reason:  statusErr.Reason,
+			Code:    statusErr.Code,
+		})
+		return
+	}
+	rw.Deny(err.Error())
+}
+
+// diffJSONPatch computes the JSONPatch operations needed to turn original
+// into modified
+func diffJSONPatch(original, modified runtime.Object) ([]jsonpatch.JsonPatchOperation, error) {
+	originalBuf, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+	modifiedBuf, err := json.Marshal(modified)
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.CreatePatch(originalBuf, modifiedBuf)
+}
@@ -0,0 +1,95 @@
+package ezadmis_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/yankeguo/ezadmis"
+	"github.com/yankeguo/ezadmis/ezadmistest"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// protectedPodValidator denies the deletion of any Pod annotated
+// "protected": "true"
+type protectedPodValidator struct{}
+
+func (protectedPodValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+func (protectedPodValidator) ValidateUpdate(ctx context.Context, oldObj, obj runtime.Object) error {
+	return nil
+}
+
+func (protectedPodValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	if obj.(*corev1.Pod).Annotations["protected"] == "true" {
+		return ezadmis.NewStatusError(403, metav1.StatusReasonForbidden, "pod is protected from deletion")
+	}
+	return nil
+}
+
+// TestNewTypedWebhookHandler_ValidateDeleteSeesOldObject is a regression test
+// for ValidateDelete being called with the decoded req.Object.Raw, which the
+// API server always leaves empty on DELETE; it must see req.OldObject.Raw instead.
+func TestNewTypedWebhookHandler_ValidateDeleteSeesOldObject(t *testing.T) {
+	pod := &corev1.Pod{}
+	pod.Annotations = map[string]string{"protected": "true"}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &admissionv1.AdmissionRequest{
+		Operation: admissionv1.Delete,
+		OldObject: runtime.RawExtension{Raw: raw},
+	}
+
+	handler := ezadmis.NewTypedWebhookHandler(ezadmis.TypedWebhookHandlerOptions{
+		Object:    &corev1.Pod{},
+		Validator: protectedPodValidator{},
+	})
+
+	result, err := ezadmistest.Invoke(context.Background(), handler, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Fatal("expected deletion of a protected pod to be denied")
+	}
+	if result.Status == nil || result.Status.Message != "pod is protected from deletion" {
+		t.Fatalf("expected the protection message, got %+v", result.Status)
+	}
+}
+
+// TestNewTypedWebhookHandler_ValidateDeleteAllowsUnprotected covers the
+// allowed path, so the regression test above isn't just exercising a
+// fail-closed default.
+func TestNewTypedWebhookHandler_ValidateDeleteAllowsUnprotected(t *testing.T) {
+	pod := &corev1.Pod{}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &admissionv1.AdmissionRequest{
+		Operation: admissionv1.Delete,
+		OldObject: runtime.RawExtension{Raw: raw},
+	}
+
+	handler := ezadmis.NewTypedWebhookHandler(ezadmis.TypedWebhookHandlerOptions{
+		Object:    &corev1.Pod{},
+		Validator: protectedPodValidator{},
+	})
+
+	result, err := ezadmistest.Invoke(context.Background(), handler, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Fatalf("expected deletion of an unprotected pod to be allowed, got status %+v", result.Status)
+	}
+}
@@ -2,16 +2,22 @@ package ezadmis
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
-	"log"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	admissionv1 "k8s.io/api/admission/v1"
 )
@@ -20,6 +26,9 @@ import (
 type WebhookResponseWriter interface {
 	// Deny deny this admission request
 	Deny(deny string)
+	// DenyStatus deny this admission request with a full metav1.Status,
+	// for callers that need to surface a specific Code/Reason
+	DenyStatus(status metav1.Status)
 	// PatchRaw append a raw JSONPatch operation
 	PatchRaw(patch map[string]interface{})
 	// PatchAdd append a JSONPatch 'add' operation
@@ -39,12 +48,17 @@ type WebhookResponseWriter interface {
 type webhookResponseWriter struct {
 	patches []map[string]interface{}
 	deny    string
+	status  *metav1.Status
 }
 
 func (w *webhookResponseWriter) Deny(deny string) {
 	w.deny = deny
 }
 
+func (w *webhookResponseWriter) DenyStatus(status metav1.Status) {
+	w.status = &status
+}
+
 func (w *webhookResponseWriter) PatchRaw(patch map[string]interface{}) {
 	w.patches = append(w.patches, patch)
 }
@@ -102,51 +116,85 @@ type WebhookHandler func(ctx context.Context, req *admissionv1.AdmissionRequest,
 // WrapWebhookHandlerOptions options for wrapping WebhookHandler
 type WrapWebhookHandlerOptions struct {
 	Debug bool
+	// Logger base logger for this handler; a child bound with per-request
+	// correlation fields is what ends up in the request context and is
+	// retrievable via LoggerFrom. Defaults to a stdr-backed logger
+	Logger logr.Logger
+	// Metrics optional, instruments every request handled by this
+	// http.HandlerFunc; nil disables instrumentation
+	Metrics *Metrics
 }
 
 // WrapWebhookHandler wrap WebhookHandler to http.HandlerFunc
 func WrapWebhookHandler(opts WrapWebhookHandlerOptions, handler WebhookHandler) http.HandlerFunc {
+	logger := opts.Logger
+	if logger.GetSink() == nil {
+		logger = defaultLogger()
+	}
+
 	return func(rw http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+
 		// automatically error returning
 		var err error
+		reqLogger := logger
 		defer func() {
 			if err == nil {
 				return
 			}
-			log.Println("failed to handle admission review:", err.Error())
+			reqLogger.Error(err, "failed to handle admission review")
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 		}()
 
-		// decode request
-		var reqReview admissionv1.AdmissionReview
-		if err = json.NewDecoder(req.Body).Decode(&reqReview); err != nil {
+		// read and decode request, accepting admission/v1 and admission/v1beta1 alike
+		var body []byte
+		if body, err = io.ReadAll(req.Body); err != nil {
+			err = errors.New("failed to read incoming AdmissionReview: " + err.Error())
+			opts.Metrics.observeDecodeError(req.URL.Path)
+			return
+		}
+
+		var reqReview *admissionv1.AdmissionReview
+		var gvk schema.GroupVersionKind
+		if reqReview, gvk, err = decodeAdmissionReview(body); err != nil {
 			err = errors.New("failed to decode incoming AdmissionReview: " + err.Error())
+			opts.Metrics.observeDecodeError(req.URL.Path)
 			return
 		}
 
+		if req := reqReview.Request; req != nil {
+			reqLogger = logger.WithValues(
+				"uid", req.UID,
+				"kind", req.Kind.String(),
+				"namespace", req.Namespace,
+				"name", req.Name,
+				"operation", req.Operation,
+				"username", req.UserInfo.Username,
+			)
+		}
+		ctx := loggerInto(req.Context(), reqLogger)
+
 		if opts.Debug {
-			log.Println("Request:")
-			raw, _ := json.Marshal(&reqReview)
-			log.Println(string(raw))
+			raw, _ := json.Marshal(reqReview)
+			reqLogger.V(1).Info("decoded admission review", "body", string(raw))
 		}
 
 		// execute handler
 		ret := &webhookResponseWriter{}
 
-		if err = handler(req.Context(), reqReview.Request, ret); err != nil {
+		if err = handler(ctx, reqReview.Request, ret); err != nil {
 			err = errors.New("failed to execute WebhookHandler: " + err.Error())
+			opts.Metrics.observe(req.URL.Path, reqReview.Request, start, 0, "error")
 			return
 		}
 
 		if opts.Debug {
-			log.Println("Patches:")
-			if len(ret.patches) == 0 {
-				log.Println("--- NONE ---")
-			} else {
-				raw, _ := json.MarshalIndent(ret.patches, "", "  ")
-				log.Println(string(raw))
-			}
-			log.Println("Deny:", ret.deny)
+			raw, _ := json.Marshal(ret.patches)
+			reqLogger.V(1).Info("webhook handler result", "patches", string(raw), "deny", ret.deny)
+		}
+
+		if ret.deny != "" || ret.status != nil {
+			reqLogger.Info("denied admission request", "deny", ret.deny)
 		}
 
 		// build response
@@ -164,8 +212,8 @@ func WrapWebhookHandler(opts WrapWebhookHandlerOptions, handler WebhookHandler)
 				*patchType = admissionv1.PatchTypeJSONPatch
 			}
 
-			var status *metav1.Status
-			if ret.deny != "" {
+			status := ret.status
+			if status == nil && ret.deny != "" {
 				status = &metav1.Status{
 					Status:  metav1.StatusFailure,
 					Message: ret.deny,
@@ -174,20 +222,25 @@ func WrapWebhookHandler(opts WrapWebhookHandlerOptions, handler WebhookHandler)
 			}
 
 			resReview = admissionv1.AdmissionReview{
-				TypeMeta: reqReview.TypeMeta,
 				Response: &admissionv1.AdmissionResponse{
 					UID:       reqReview.Request.UID,
-					Allowed:   ret.deny == "",
+					Allowed:   status == nil,
 					Result:    status,
 					Patch:     patch,
 					PatchType: patchType,
 				},
 			}
+
+			result := "allowed"
+			if status != nil {
+				result = "denied"
+			}
+			opts.Metrics.observe(req.URL.Path, reqReview.Request, start, len(patch), result)
 		}
 
-		// send response
+		// send response, marshaled using the same GroupVersionKind the client sent
 		var buf []byte
-		if buf, err = json.Marshal(resReview); err != nil {
+		if buf, err = encodeAdmissionReview(&resReview, gvk); err != nil {
 			err = errors.New("failed to marshal outgoing AdmissionReview: " + err.Error())
 			return
 		}
@@ -208,6 +261,13 @@ type WebhookServer interface {
 
 	// Shutdown wraps internal http.Server#Shutdown()
 	Shutdown(ctx context.Context) error
+
+	// Register mounts handler as a WebhookHandler on path, alongside the
+	// Handler/Handlers configured via WebhookServerOptions
+	Register(path string, handler WebhookHandler)
+
+	// Mount mounts an arbitrary http.Handler on path, e.g. metrics or pprof
+	Mount(path string, handler http.Handler)
 }
 
 // WebhookServerOptions options for WebhookServer
@@ -216,7 +276,26 @@ type WebhookServerOptions struct {
 	CertFile string
 	KeyFile  string
 	Debug    bool
-	Handler  WebhookHandler
+	// Logger base logger passed to every WrapWebhookHandlerOptions; defaults
+	// to a stdr-backed logger
+	Logger logr.Logger
+	// CertReloadInterval how often to re-stat CertFile/KeyFile for changes,
+	// in addition to the fsnotify watch; defaults to 10s. The certificate is
+	// served through tls.Config#GetCertificate, so cert-manager (or
+	// BootstrapTLS) rotations are picked up without a restart. If the file
+	// watch cannot be set up, CertFile/KeyFile are instead loaded once by
+	// ListenAndServe and rotations are not picked up
+	CertReloadInterval time.Duration
+	// MetricsRegisterer registers the built-in Prometheus collectors; nil
+	// uses prometheus.DefaultRegisterer. /metrics is mounted automatically
+	MetricsRegisterer prometheus.Registerer
+	// Handler sugar for Handlers["/"]; ignored if Handlers["/"] is also set
+	Handler WebhookHandler
+	// Handlers mounts each WebhookHandler on its own path via an
+	// http.ServeMux, allowing a single WebhookServer to expose several
+	// webhook endpoints (e.g. "/mutate-pods", "/validate-deployments")
+	// behind one TLS cert
+	Handlers map[string]WebhookHandler
 }
 
 var (
@@ -233,11 +312,27 @@ func DefaultWebhookServerOptions() WebhookServerOptions {
 }
 
 type webhookServer struct {
-	opts WebhookServerOptions
-	s    *http.Server
+	opts       WebhookServerOptions
+	mux        *http.ServeMux
+	s          *http.Server
+	cancelCert context.CancelFunc
+	metrics    *Metrics
+}
+
+func (w *webhookServer) Register(path string, handler WebhookHandler) {
+	w.mux.HandleFunc(path, WrapWebhookHandler(WrapWebhookHandlerOptions{Debug: w.opts.Debug, Logger: w.opts.Logger, Metrics: w.metrics}, handler))
+}
+
+func (w *webhookServer) Mount(path string, handler http.Handler) {
+	w.mux.Handle(path, handler)
 }
 
 func (w *webhookServer) ListenAndServe() error {
+	if w.s.TLSConfig != nil && w.s.TLSConfig.GetCertificate != nil {
+		// certificate is served through tls.Config#GetCertificate, so the
+		// files are intentionally left blank here
+		return w.s.ListenAndServeTLS("", "")
+	}
 	return w.s.ListenAndServeTLS(w.opts.CertFile, w.opts.KeyFile)
 }
 
@@ -250,16 +345,24 @@ func (w *webhookServer) ListenAndServeGracefully() (err error) {
 		chErr <- w.ListenAndServe()
 	}()
 
+	logger := w.opts.Logger
+	if logger.GetSink() == nil {
+		logger = defaultLogger()
+	}
+
 	select {
 	case err = <-chErr:
 	case sig := <-chSig:
-		log.Println("signal caught:", sig.String())
+		logger.Info("signal caught", "signal", sig.String())
 		err = w.Shutdown(context.Background())
 	}
 	return
 }
 
 func (w *webhookServer) Shutdown(ctx context.Context) error {
+	if w.cancelCert != nil {
+		w.cancelCert()
+	}
 	return w.s.Shutdown(ctx)
 }
 
@@ -275,21 +378,66 @@ func NewWebhookServer(opts WebhookServerOptions) WebhookServer {
 	if opts.KeyFile == "" {
 		opts.KeyFile = dfo.KeyFile
 	}
-	if opts.Handler == nil {
-		opts.Handler = func(_ context.Context, _ *admissionv1.AdmissionRequest, _ WebhookResponseWriter) error {
+	// Handler is sugar for Handlers["/"]; an explicit Handlers["/"] wins if
+	// both are set, so the "/" pattern is only ever registered once
+	handlers := make(map[string]WebhookHandler, len(opts.Handlers)+1)
+	for path, handler := range opts.Handlers {
+		handlers[path] = handler
+	}
+	if opts.Handler != nil {
+		if _, ok := handlers["/"]; !ok {
+			handlers["/"] = opts.Handler
+		}
+	}
+	if len(handlers) == 0 {
+		handlers["/"] = func(_ context.Context, _ *admissionv1.AdmissionRequest, _ WebhookResponseWriter) error {
 			return nil
 		}
 	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", handleHealth)
+	mux.HandleFunc("/readyz", handleHealth)
+
+	metrics := NewMetrics(opts.MetricsRegisterer)
+	mux.Handle("/metrics", promhttp.HandlerFor(gathererFor(opts.MetricsRegisterer), promhttp.HandlerOpts{}))
+
+	for path, handler := range handlers {
+		mux.HandleFunc(path, WrapWebhookHandler(WrapWebhookHandlerOptions{Debug: opts.Debug, Logger: opts.Logger, Metrics: metrics}, handler))
+	}
+
+	logger := opts.Logger
+	if logger.GetSink() == nil {
+		logger = defaultLogger()
+	}
+
+	var tlsConfig *tls.Config
+	certCtx, cancelCert := context.WithCancel(context.Background())
+	if reloader, err := newCertReloader(certCtx, opts.CertFile, opts.KeyFile, opts.CertReloadInterval); err == nil {
+		tlsConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
+	} else {
+		cancelCert()
+		cancelCert = nil
+		logger.Error(err, "failed to set up TLS certificate auto-reload, falling back to static CertFile/KeyFile")
+	}
+
 	return &webhookServer{
 		opts: opts,
+		mux:  mux,
 		s: &http.Server{
-			Addr: ":" + strconv.Itoa(opts.Port),
-			Handler: WrapWebhookHandler(
-				WrapWebhookHandlerOptions{
-					Debug: opts.Debug,
-				},
-				opts.Handler,
-			),
+			Addr:      ":" + strconv.Itoa(opts.Port),
+			Handler:   mux,
+			TLSConfig: tlsConfig,
 		},
+		cancelCert: cancelCert,
+		metrics:    metrics,
 	}
 }
+
+// handleHealth is the built-in /healthz and /readyz handler; the process is
+// considered healthy and ready as long as it's able to serve HTTP at all
+func handleHealth(rw http.ResponseWriter, _ *http.Request) {
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write([]byte("ok"))
+}